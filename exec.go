@@ -0,0 +1,57 @@
+package main
+
+/*
+ * exec.go - the single place a TestCase actually gets executed, shared by
+ * the sequential retry/fail-fast path (policy.go) and the parallel worker
+ * pool (parallel.go). Centralizing it
+ * here means the -t per-test timeout is enforced exactly once, everywhere
+ * a TestCase runs.
+ *
+ * Concurrent executions only ever touch their own *atf.TestCase, so they
+ * don't need to exclude each other - only a full report read (handleStatus
+ * / handleReportHTML in httpserver.go, which walks every TestCase in
+ * r.tr) needs a stable snapshot. So r.reportMu is held the other way round
+ * from the usual RWMutex convention: execution takes RLock (many
+ * executions run concurrently), and the full-report readers take the
+ * exclusive Lock (so they never observe a part-written TestCase).
+ */
+import (
+	"bitbucket.org/miranr/atf"
+	"fmt"
+	"time"
+)
+
+// execCaseOnce runs a single attempt of tc, enforcing r.timeout if set: if
+// tc doesn't finish before the timeout elapses, it is killed and reported
+// as StatusTimeout. warn is called with the timeout message, if any, so
+// each caller can route it through its own logger (the sequential path
+// logs directly; the parallel path needs the worker-id prefix and event
+// fan-out that logS provides).
+func (r *Runner) execCaseOnce(tc *atf.TestCase, fn atf.ExecDisplayFnCback, warn func(msg string)) string {
+	done := make(chan struct{})
+	go func() {
+		r.reportMu.RLock()
+		tc.Execute(&fn)
+		r.reportMu.RUnlock()
+		close(done)
+	}()
+
+	if r.timeout <= 0 {
+		<-done
+		return tc.Status
+	}
+
+	select {
+	case <-done:
+		return tc.Status
+	case <-time.After(r.timeout):
+		// tc.Execute is still running (and still holding the RLock) on the
+		// goroutine above - Kill must not wait on reportMu, or a hung
+		// process could never be interrupted.
+		tc.Kill()
+		if warn != nil {
+			warn(fmt.Sprintf("Test case %q timed out after %s\n", tc.Name, r.timeout))
+		}
+		return StatusTimeout
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+/*
+ * httpserver.go - optional embedded HTTP server (-http flag) that lets
+ * users watch a long-running suite from a browser, or lets a CI dashboard
+ * poll status, without waiting for CreateReports to finish. Exposes:
+ *
+ *   GET /status      - the current TestReport, as JSON
+ *   GET /report.html - the partial HTML report built so far
+ *   GET /events      - a Server-Sent Events stream of log records and
+ *                      testcase state transitions as they happen
+ *
+ * There's no vendored WebSocket library in this tree, so /events uses SSE -
+ * plain net/http is enough to push one-way updates to a browser or curl.
+ */
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// liveEvent is a single update pushed to /events subscribers: either a log
+// record (Testcase/Status empty) or a testcase state transition
+// (Level/Message empty).
+type liveEvent struct {
+	Level    string `json:"level"`
+	Message  string `json:"message"`
+	Testcase string `json:"testcase,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// eventBroadcaster fans a stream of liveEvents out to every connected
+// /events subscriber.
+type eventBroadcaster struct {
+	mu   sync.Mutex
+	subs map[chan liveEvent]struct{}
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{subs: make(map[chan liveEvent]struct{})}
+}
+
+// subscribe registers a new subscriber channel; call unsubscribe when the
+// client disconnects.
+func (b *eventBroadcaster) subscribe() chan liveEvent {
+	ch := make(chan liveEvent, 32)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *eventBroadcaster) unsubscribe(ch chan liveEvent) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+	close(ch)
+}
+
+// publish fans out ev to every current subscriber; a slow subscriber whose
+// buffer is full simply misses the event rather than blocking the run.
+func (b *eventBroadcaster) publish(ev liveEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// startHTTPServer starts the embedded status/report/events server in the
+// background. It returns immediately; the server is torn down when r.Run()
+// finishes executing the test set.
+func (r *Runner) startHTTPServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", r.handleStatus)
+	mux.HandleFunc("/report.html", r.handleReportHTML)
+	mux.HandleFunc("/events", r.handleEvents)
+
+	srv := &http.Server{Addr: r.httpAddr, Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			r.logger.Error(fmt.Sprintf("HTTP server error: %s\n", err))
+		}
+	}()
+	return srv
+}
+
+// handleStatus reads r.tr under reportMu's exclusive lock: execCaseOnce
+// (exec.go) holds the RLock for the duration of each running testcase, so
+// taking Lock() here waits for every in-flight execution to reach a
+// consistent point before walking the whole TestSet - without it, polling
+// /status during a parallel run would race with the worker goroutines
+// mutating the same *atf.TestCase objects.
+func (r *Runner) handleStatus(w http.ResponseWriter, req *http.Request) {
+	r.reportMu.Lock()
+	body, err := r.tr.JSON()
+	r.reportMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, body)
+}
+
+// handleReportHTML reads r.tr under reportMu's exclusive lock; see handleStatus.
+func (r *Runner) handleReportHTML(w http.ResponseWriter, req *http.Request) {
+	html := r.createHTMLHeader(r.tr.TestSet.Name)
+	html += "<body>\n"
+	r.reportMu.Lock()
+	body, err := r.tr.HTML()
+	r.reportMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	html += body
+	html += "</body>\n</html>\n"
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprint(w, html)
+}
+
+func (r *Runner) handleEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := r.events.subscribe()
+	defer r.events.unsubscribe(ch)
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "data: {\"level\":%q,\"message\":%q,\"testcase\":%q,\"status\":%q}\n\n",
+				ev.Level, ev.Message, ev.Testcase, ev.Status)
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
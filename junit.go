@@ -0,0 +1,148 @@
+package main
+
+/*
+ * junit.go - JUnit XML report emitter, so CI systems (Jenkins, GitLab CI,
+ * CircleCI, ...) can consume atfrunner results natively without a custom
+ * parser. Lives alongside the HTML/XML/JSON emitters in runner.go and is
+ * wired into CreateReports the same way.
+ */
+import (
+	"bitbucket.org/miranr/atf"
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+// junitTestsuites is the root element of a JUnit report: atf's TestSet maps
+// to a single <testsuite>, wrapped in <testsuites> as most CI consumers
+// expect.
+type junitTestsuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestsuite `xml:"testsuite"`
+}
+
+// junitTestsuite maps to an atf.TestSet.
+type junitTestsuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestcase `xml:"testcase"`
+}
+
+// junitTestcase maps to an atf.TestCase.
+type junitTestcase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+// junitFailure maps a failed/errored step to a <failure>/<error> element.
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// createJUnitReport serializes the TestReport into the JUnit XML schema and
+// writes it to filename.
+func (r *Runner) createJUnitReport(filename string) error {
+	suite := junitTestsuite{Name: r.tr.TestSet.Name}
+
+	for _, tc := range r.tr.TestSet.Cases {
+		suite.Tests++
+		timing := r.timingFor(tc.Name)
+		jc := junitTestcase{
+			Classname: r.tr.TestSet.Name,
+			Name:      tc.Name,
+			Time:      timing.Finished.Sub(timing.Started).Seconds(),
+			SystemOut: attemptsSummary(timing.Attempts) + tc.Stdout,
+			SystemErr: tc.Stderr,
+		}
+
+		switch {
+		case timing.Status == StatusSkipped:
+			jc.Skipped = &struct{}{}
+		case timing.Status == StatusTimeout:
+			jc.Error = &junitFailure{
+				Message: "test case timed out",
+				Type:    StatusTimeout,
+				Content: fmt.Sprintf("test case %q exceeded its configured timeout", tc.Name),
+			}
+			suite.Errors++
+		default:
+			if step := firstFailedStep(tc); step != nil {
+				f := &junitFailure{Message: step.Message, Type: step.Status, Content: step.Message}
+				if step.Status == "ERROR" {
+					jc.Error = f
+					suite.Errors++
+				} else {
+					jc.Failure = f
+					suite.Failures++
+				}
+			}
+		}
+
+		suite.Time += jc.Time
+		suite.Cases = append(suite.Cases, jc)
+	}
+
+	report := junitTestsuites{Suites: []junitTestsuite{suite}}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fout, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer fout.Close()
+
+	fmt.Fprint(fout, xml.Header)
+	_, err = fout.Write(data)
+	return err
+}
+
+// firstFailedStep returns the first non-passing step of tc, or nil if every
+// step passed (or tc has no steps recorded).
+func firstFailedStep(tc *atf.TestCase) *atf.Step {
+	for i := range tc.Steps {
+		if tc.Steps[i].Status != "PASS" {
+			return &tc.Steps[i]
+		}
+	}
+	return nil
+}
+
+// timingFor returns the recorded caseTiming for the named testcase; every
+// run path (parallel.go, policy.go) records one entry per testcase, so the
+// zero value is only returned if name doesn't match any case in this run.
+func (r *Runner) timingFor(name string) caseTiming {
+	for _, t := range r.timings {
+		if t.Name == name {
+			return t
+		}
+	}
+	return caseTiming{}
+}
+
+// attemptsSummary renders a one-line-per-attempt history to prefix the
+// <system-out> of a retried testcase; it's empty when there was only a
+// single attempt.
+func attemptsSummary(attempts []attemptRecord) string {
+	if len(attempts) <= 1 {
+		return ""
+	}
+	s := ""
+	for _, a := range attempts {
+		s += fmt.Sprintf("attempt %d: %s (%s)\n", a.Attempt, a.Status, a.Finished.Sub(a.Started))
+	}
+	return s
+}
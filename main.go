@@ -11,21 +11,54 @@ import (
 
 /************************************************
  * parseArgs - parge command-line arguments
+ *
+ * Registers each flag with r's current field value as its default, so
+ * values already applied from the home config / ATFRUNNER_* env vars
+ * (see config.go) survive unless the user passes the flag explicitly.
+ * Returns the set of config keys the user explicitly overrode on the CLI,
+ * so the "runner" section of the -i input file can be layered in without
+ * clobbering them.
  */
-func parseArgs(r *Runner) {
-	flag.StringVar(&r.input, "i", "", "Input configuration path")
-	flag.StringVar(&r.workdir, "w", "", "Working directory path")
-	flag.StringVar(&r.logfile, "l", "", "Logfile name")
-	flag.StringVar(&r.syslog, "s", "", "Syslog server IP")
-	flag.StringVar(&r.report, "r", "", "final report filename")
-	flag.StringVar(&r.cssfile, "c", "cfg/report_def.css",
-		"custom CSS file for HTML report")
-	flag.BoolVar(&r.xml, "X", false, "create XML report (beside HTML report)")
-	flag.BoolVar(&r.json, "J", false, "create JSON report (beside HTML report)")
-	flag.BoolVar(&r.debug, "d", false,
+func parseArgs(r *Runner) map[string]bool {
+	flag.StringVar(&r.input, "i", r.input, "Input configuration path")
+	flag.StringVar(&r.workdir, "w", r.workdir, "Working directory path")
+	flag.StringVar(&r.logfile, "l", r.logfile, "Logfile name")
+	flag.StringVar(&r.syslog, "s", r.syslog, "Syslog server IP")
+	flag.StringVar(&r.report, "r", r.report, "final report filename")
+	cssfile := r.cssfile
+	if cssfile == "" {
+		cssfile = "cfg/report_def.css"
+	}
+	flag.StringVar(&r.cssfile, "c", cssfile, "custom CSS file for HTML report")
+	flag.BoolVar(&r.xml, "X", r.xml, "create XML report (beside HTML report)")
+	flag.BoolVar(&r.json, "J", r.json, "create JSON report (beside HTML report)")
+	flag.BoolVar(&r.junit, "junit", r.junit,
+		"create JUnit XML report (beside HTML report), for CI integration")
+	flag.BoolVar(&r.debug, "d", r.debug,
 		"enable debug mode (for testing purposes)")
+	flag.BoolVar(&r.par, "par", r.par, "run tests in parallel (see -j for worker count)")
+	flag.IntVar(&r.workers, "j", r.workers,
+		"number of worker goroutines used for parallel execution")
+	flag.DurationVar(&r.timeout, "t", r.timeout,
+		"per-testcase timeout (e.g. '30s'); 0 disables the timeout")
+	flag.StringVar(&r.httpAddr, "http", r.httpAddr,
+		"address for the embedded live status server, e.g. ':8080'; empty disables it")
+	flag.IntVar(&r.retries, "retries", r.retries,
+		"default number of retries for a failing testcase")
+	flag.DurationVar(&r.retryBackoff, "retryBackoff", r.retryBackoff,
+		"wait between retry attempts (e.g. '5s')")
+	flag.BoolVar(&r.failFast, "failFast", r.failFast,
+		"cancel remaining testcases on the first non-retryable failure")
 	//
 	flag.Parse()
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := cliToConfigKey[f.Name]; ok {
+			explicit[key] = true
+		}
+	})
+	return explicit
 }
 
 /*
@@ -34,10 +67,15 @@ func parseArgs(r *Runner) {
 func main() {
 //	    atf.RunBats() // for testing purposes : test/bats.go
 	r := NewRunner()
-	// parse CLI arguments
-	parseArgs(r)
-	// initialize new Runner; if initializaton fails, exit gracefully 
-	err := r.initialize()
+	// layer in ~/.atfrunner.yaml and ATFRUNNER_* env vars before CLI flags
+	if err := applyHomeConfig(r); err != nil {
+		fmt.Println("Warning: could not read home config:", err)
+	}
+	applyEnvConfig(r)
+	// parse CLI arguments; explicit flags win over everything else
+	explicit := parseArgs(r)
+	// initialize new Runner; if initializaton fails, exit gracefully
+	err := r.initialize(explicit)
 	if err != nil {
 		fmt.Println(err)
 		fmt.Println("Please define the input configuration file")
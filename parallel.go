@@ -0,0 +1,146 @@
+package main
+
+/*
+ * parallel.go - bounded worker-pool executor for running TestCases
+ * concurrently. Log lines are serialized so concurrent tests never
+ * interleave, and per-testcase start/finish timestamps are recorded so the
+ * HTML/XML/JSON reports can render a Gantt-style timeline of the run.
+ */
+import (
+	"bitbucket.org/miranr/atf"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StatusTimeout marks a TestCase that was killed because it ran longer than
+// the configured per-test timeout (-t flag).
+const StatusTimeout = "TIMEOUT"
+
+// caseTiming records the start/finish timestamps and outcome of a single
+// TestCase run, so reports can render a Gantt-style timeline of the
+// parallel execution.
+type caseTiming struct {
+	Worker   int
+	Name     string
+	Started  time.Time
+	Finished time.Time
+	Status   string
+	Attempts []attemptRecord // one entry per retry attempt (see policy.go)
+}
+
+// runParallel dispatches the TestCases of r.tr.TestSet across a bounded
+// pool of r.workers goroutines (see -j flag). Per-worker output isolation
+// is not implemented: atf.TestCase (bitbucket.org/miranr/atf, not part of
+// this tree) has no working-directory field to point at a per-worker
+// subdirectory, so concurrently running tests share whatever output
+// location atf.TestCase.Execute already resolves on its own.
+func (r *Runner) runParallel() {
+	cases := r.tr.TestSet.Cases
+	if len(cases) == 0 {
+		return
+	}
+
+	workers := r.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(cases) {
+		workers = len(cases)
+	}
+
+	jobs := make(chan *atf.TestCase)
+	var logMu sync.Mutex
+	var timingsMu sync.Mutex
+	r.timings = make([]caseTiming, 0, len(cases))
+
+	// logS funnels every log record through a single mutex, so lines coming
+	// from concurrently executing tests are never interleaved.
+	logS := func(worker int, lvl, msg string) {
+		logMu.Lock()
+		defer logMu.Unlock()
+		line := fmt.Sprintf("[worker-%d] %s", worker, msg)
+		r.logger.LogS(lvl, line)
+		r.events.publish(liveEvent{Level: lvl, Message: line})
+	}
+
+	// ctx is cancelled on the first non-retryable failure when -failFast is
+	// set; the dispatch loop then stops handing out remaining cases and
+	// records them as SKIPPED instead.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for id := 0; id < workers; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for tc := range jobs {
+				r.runOneCase(id, tc, logS, &timingsMu, cancel)
+			}
+		}(id)
+	}
+
+	for _, tc := range cases {
+		if ctx.Err() != nil {
+			timingsMu.Lock()
+			r.recordSkipped(tc)
+			timingsMu.Unlock()
+			continue
+		}
+		select {
+		case jobs <- tc:
+		case <-ctx.Done():
+			timingsMu.Lock()
+			r.recordSkipped(tc)
+			timingsMu.Unlock()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// runOneCase executes a single TestCase on worker id (which only tags its
+// log lines; there is no per-worker directory), applying its retry policy (see policy.go) and enforcing the
+// configured per-test timeout (if any). It appends the case's timing and
+// attempt history to r.timings for the report, and cancels the shared
+// context if the case ultimately failed and -failFast is set.
+func (r *Runner) runOneCase(id int, tc *atf.TestCase, logS func(worker int, lvl, msg string), timingsMu *sync.Mutex, cancel context.CancelFunc) {
+	fn := atf.ExecDisplayFnCback(func(params ...string) {
+		if len(params) < 2 {
+			panic("Callback: Wrong number of parameters.")
+		}
+		logS(id, params[0], params[1])
+	})
+
+	policy := r.policyFor(tc.Name)
+	attempts, status := execWithPolicy(policy, func(attempt int) string {
+		if attempt > 1 {
+			logS(id, "warning", fmt.Sprintf("Retrying %q (attempt %d/%d)\n", tc.Name, attempt, policy.Retries+1))
+			if policy.Backoff > 0 {
+				time.Sleep(policy.Backoff)
+			}
+		}
+
+		return r.execCaseOnce(tc, fn, func(msg string) { logS(id, "error", msg) })
+	})
+
+	timingsMu.Lock()
+	r.timings = append(r.timings, caseTiming{
+		Worker:   id,
+		Name:     tc.Name,
+		Started:  attempts[0].Started,
+		Finished: attempts[len(attempts)-1].Finished,
+		Status:   status,
+		Attempts: attempts,
+	})
+	timingsMu.Unlock()
+
+	r.events.publish(liveEvent{Testcase: tc.Name, Status: status})
+
+	if status != "PASS" && r.failFast {
+		logS(id, "error", fmt.Sprintf("Fail-fast: %q failed, cancelling remaining test cases.\n", tc.Name))
+		cancel()
+	}
+}
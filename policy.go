@@ -0,0 +1,167 @@
+package main
+
+/*
+ * policy.go - per-testcase retry-on-failure and fail-fast execution
+ * policies, applied the same way whether the suite runs sequentially or
+ * through the parallel worker pool (see parallel.go). The global
+ * -retries/-retryBackoff/-failFast CLI flags set the default policy; the
+ * "policies" section of the input file can override retries/retryBackoff
+ * per testcase.
+ */
+import (
+	"bitbucket.org/miranr/atf"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// StatusSkipped marks a TestCase that fail-fast cancelled before it ran.
+const StatusSkipped = "SKIPPED"
+
+// attemptRecord records the outcome of a single retry attempt, so reports
+// can show per-attempt timings rather than just the final outcome.
+type attemptRecord struct {
+	Attempt  int
+	Started  time.Time
+	Finished time.Time
+	Status   string
+}
+
+// casePolicy is the retry policy in effect for one testcase.
+type casePolicy struct {
+	Retries int           `json:"retries"`
+	Backoff time.Duration `json:"retryBackoff"`
+}
+
+// policiesSection is the shape of the top-level "policies" key in the input
+// JSON config file: a map of testcase name to its policy override.
+type policiesSection struct {
+	Policies map[string]rawCasePolicy `json:"policies"`
+}
+
+// rawCasePolicy mirrors casePolicy but keeps Backoff as a string, since
+// encoding/json can't unmarshal a duration like "5s" straight into a
+// time.Duration.
+type rawCasePolicy struct {
+	Retries      int    `json:"retries"`
+	RetryBackoff string `json:"retryBackoff"`
+}
+
+// loadPolicies reads the "policies" section out of the input config file. A
+// missing section is not an error - callers get an empty map and every
+// testcase falls back to the Runner-wide default policy.
+func loadPolicies(input string) (map[string]casePolicy, error) {
+	policies := map[string]casePolicy{}
+	if input == "" {
+		return policies, nil
+	}
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return nil, err
+	}
+	var section policiesSection
+	if err := json.Unmarshal(data, &section); err != nil {
+		return nil, err
+	}
+	for name, raw := range section.Policies {
+		p := casePolicy{Retries: raw.Retries}
+		if raw.RetryBackoff != "" {
+			d, err := time.ParseDuration(raw.RetryBackoff)
+			if err != nil {
+				return nil, fmt.Errorf("policies.%s.retryBackoff: %s", name, err)
+			}
+			p.Backoff = d
+		}
+		policies[name] = p
+	}
+	return policies, nil
+}
+
+// policyFor resolves the retry policy for testcase name: the per-testcase
+// override from the "policies" input-file section if present, otherwise
+// the Runner-wide -retries/-retryBackoff default.
+func (r *Runner) policyFor(name string) casePolicy {
+	if p, ok := r.policies[name]; ok {
+		return p
+	}
+	return casePolicy{Retries: r.retries, Backoff: r.retryBackoff}
+}
+
+// execWithPolicy runs exec (one attempt of a testcase) up to
+// policy.Retries+1 times. It does not wait between attempts itself: exec
+// gets the attempt number and sleeps policy.Backoff before a retry. exec
+// must return the case's outcome status ("PASS", "FAIL", "ERROR",
+// "TIMEOUT"...); any status other than "PASS" is retried.
+func execWithPolicy(policy casePolicy, exec func(attempt int) string) (attempts []attemptRecord, final string) {
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		status := exec(attempt)
+		finished := time.Now()
+		attempts = append(attempts, attemptRecord{
+			Attempt: attempt, Started: started, Finished: finished, Status: status,
+		})
+
+		if status == "PASS" || attempt > policy.Retries {
+			return attempts, status
+		}
+	}
+}
+
+// runSequentialWithPolicy executes the TestSet's cases one at a time via
+// execCaseOnce (exec.go), applying retry and fail-fast policies and the -t
+// timeout; it is the only sequential run path (see Runner.Run).
+func (r *Runner) runSequentialWithPolicy(fn atf.ExecDisplayFnCback) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, tc := range r.tr.TestSet.Cases {
+		if ctx.Err() != nil {
+			r.recordSkipped(tc)
+			continue
+		}
+
+		policy := r.policyFor(tc.Name)
+		attempts, status := execWithPolicy(policy, func(attempt int) string {
+			if attempt > 1 {
+				r.logger.Warning(fmt.Sprintf("Retrying %q (attempt %d/%d)\n",
+					tc.Name, attempt, policy.Retries+1))
+				if policy.Backoff > 0 {
+					time.Sleep(policy.Backoff)
+				}
+			}
+			return r.execCaseOnce(tc, fn, func(msg string) { r.logger.Warning(msg) })
+		})
+
+		r.recordAttempts(tc.Name, attempts, status)
+
+		if status != "PASS" && r.failFast {
+			r.logger.Warning(fmt.Sprintf("Fail-fast: %q failed, cancelling remaining test cases.\n", tc.Name))
+			cancel()
+		}
+	}
+}
+
+// recordAttempts appends the final timing/status of a finished testcase to
+// r.timings, along with its full per-attempt history.
+func (r *Runner) recordAttempts(name string, attempts []attemptRecord, status string) {
+	if len(attempts) == 0 {
+		return
+	}
+	r.timings = append(r.timings, caseTiming{
+		Name:     name,
+		Started:  attempts[0].Started,
+		Finished: attempts[len(attempts)-1].Finished,
+		Status:   status,
+		Attempts: attempts,
+	})
+	r.events.publish(liveEvent{Testcase: name, Status: status})
+}
+
+// recordSkipped appends a SKIPPED entry for a testcase that fail-fast
+// cancelled before it got a chance to run.
+func (r *Runner) recordSkipped(tc *atf.TestCase) {
+	r.timings = append(r.timings, caseTiming{Name: tc.Name, Status: StatusSkipped})
+	r.events.publish(liveEvent{Testcase: tc.Name, Status: StatusSkipped})
+}
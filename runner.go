@@ -9,26 +9,40 @@ import (
 	"bitbucket.org/miranr/atf/utils"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 )
 
 // Runner is structure that holds all data needed for running the application.
 type Runner struct {
-	tr      *atf.TestReport // TestSet that's be run
-	input   string          // input configuration file (currently only JSON)
-	workdir string          // working directory
-	logfile string
-	syslog  string
-	report  string
-	cssfile string
-	xml     bool       // create XML report (beside HTML report)
-	json    bool       // create JSON report (beside HTML report)
-	par     bool       // run tests in parallel? (default: false) TODO
-	debug   bool       // enable debug mode (for testing purposes only)
-	logger  *utils.Log // a logger instance
+	tr           *atf.TestReport        // TestSet that's be run
+	input        string                 // input configuration file (currently only JSON)
+	workdir      string                 // working directory
+	logfile      string
+	syslog       string
+	report       string
+	cssfile      string
+	xml          bool                   // create XML report (beside HTML report)
+	json         bool                   // create JSON report (beside HTML report)
+	junit        bool                   // create JUnit XML report (beside HTML report)
+	par          bool                   // run tests in parallel? (default: false) (-par flag)
+	workers      int                    // number of worker goroutines used when par is true (-j flag)
+	timeout      time.Duration          // per-testcase timeout; 0 means no timeout (-t flag)
+	debug        bool                   // enable debug mode (for testing purposes only)
+	logger       *utils.Log             // a logger instance
+	timings      []caseTiming           // per-testcase start/finish/status, filled in for every run path (see parallel.go, policy.go)
+	reportMu     sync.RWMutex           // RLock per in-flight execution (exec.go), exclusive Lock for full-report reads (httpserver.go) - see exec.go
+	httpAddr     string                 // address for the embedded status server, e.g. ":8080" (-http flag); empty disables it
+	events       *eventBroadcaster      // fans log records & testcase transitions out to /events subscribers
+	retries      int                    // default number of retries on a failing testcase (-retries flag)
+	retryBackoff time.Duration          // wait between retry attempts (-retryBackoff flag)
+	failFast     bool                   // cancel remaining testcases on the first non-retryable failure (-failFast flag)
+	policies     map[string]casePolicy  // per-testcase retry policy overrides, from the "policies" input-file section
 }
 
 // NewRunner creates new Runner instance and return its pointer.
@@ -37,6 +51,9 @@ func NewRunner() *Runner {
 	var r = new(Runner)
 	r.logger = utils.NewLog()
 	r.par = false // run sequentially by default
+	r.workers = runtime.NumCPU()
+	r.events = newEventBroadcaster()
+	r.policies = map[string]casePolicy{}
 	return r
 }
 
@@ -52,6 +69,15 @@ func (r *Runner) display(complete bool) {
 	fmt.Printf("(Optional) CCS file for HTML report: %q\n", r.cssfile)
 	fmt.Printf("Debug node enabled? %t\n", r.debug)
 	fmt.Printf("Parallel execution? %t\n", r.par)
+	if r.par {
+		fmt.Printf("Workers: %d\n", r.workers)
+	}
+	fmt.Printf("Per-testcase timeout: %s\n", r.timeout)
+	if r.httpAddr != "" {
+		fmt.Printf("Live status server: %q\n", r.httpAddr)
+	}
+	fmt.Printf("Default retries: %d (backoff %s)\n", r.retries, r.retryBackoff)
+	fmt.Printf("Fail-fast? %t\n", r.failFast)
 
 	// display loggers
 	fmt.Printf("Loggers:\n")
@@ -107,16 +133,9 @@ func (r *Runner) collect() (err error) {
 	return
 }
 
-// Let's define the default levels for different log handlers: all text goes only to file logger, console should take only the most
-// important printous, while syslog handler should omit sending the execution outputs.
-const (
-	defSyslogLevel utils.Severity = utils.Notice
-	defFileLevel   utils.Severity = utils.Informational
-	defStreamLevel utils.Severity = utils.Notice
-)
-
-// the max number of loggers used here (console, file & syslog)
-//const numOfLoggers int = 3
+// defFormat is the fallback format string used by a backend that doesn't
+// specify its own in the "logging" config section.
+const defFormat = "%s %s %s"
 
 // Creates all needed log handlers.
 func (r *Runner) createLog() error {
@@ -136,8 +155,7 @@ func (r *Runner) createLog() error {
 	}
 	r.logfile = logfile
 	// now the real thing...
-	format := "%s %s %s"
-	err := r.createLoggers(format, r.debug)
+	err := r.createLoggers(r.debug)
 	if err != nil {
 		return err
 	}
@@ -148,46 +166,95 @@ func (r *Runner) createLog() error {
 	return nil
 }
 
-// this function actually creates all the log handlers.
-func (r *Runner) createLoggers(format string, debug bool) error {
-	// first, we define log levels (severity)
-	fLevel := defFileLevel   // this is level for file handler
-	sLevel := defSyslogLevel // this is level for syslog & console handlers
-	if debug {
-		fLevel = utils.Debug
-		sLevel = utils.Debug
-	}
-	// now create file logger
-	f, err := utils.NewFileHandler(r.logfile, format, fLevel)
+// createLoggers builds one handler per backend described in the "logging"
+// section of the input config (see logging.go); if the config defines no
+// "logging" section, it falls back to the historic file+console(+syslog)
+// setup so existing config files keep working unchanged.
+func (r *Runner) createLoggers(debug bool) error {
+	backends, err := loadBackendConfigs(r.input)
 	if err != nil {
 		return err
 	}
-	if f != nil {
-		r.logger.Handlers = r.logger.AddHandler(f)
+	if len(backends) == 0 {
+		backends = r.defaultBackendConfigs()
+	}
+
+	for _, b := range backends {
+		lvl := b.severity(debug)
+		format := b.Format
+		if format == "" {
+			format = defFormat
+		}
+
+		switch b.Type {
+		case backendFile:
+			file := b.File
+			if file == "" {
+				file = r.logfile
+			}
+			f, err := utils.NewFileHandler(file, format, lvl)
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				r.logger.Handlers = r.logger.AddHandler(f)
+			}
+		case backendConsole:
+			l := utils.NewStreamHandler(format, lvl)
+			if l != nil {
+				r.logger.Handlers = r.logger.AddHandler(l)
+			}
+		case backendSyslog:
+			addr := b.SyslogAddr
+			if addr == "" {
+				addr = r.syslog
+			}
+			if addr == "" {
+				continue
+			}
+			s := utils.NewSyslogHandler(addr, format, lvl)
+			if s != nil {
+				r.logger.Handlers = r.logger.AddHandler(s)
+			}
+		default:
+			return fmt.Errorf("unknown logging backend type %q", b.Type)
+		}
 	}
-	// and create console logger
-	l := utils.NewStreamHandler(format, sLevel)
-	if l != nil {
-		r.logger.Handlers = r.logger.AddHandler(l)
+	return nil
+}
+
+// defaultBackendConfigs returns the historic file+console(+syslog) setup,
+// used whenever the input config carries no "logging" section.
+func (r *Runner) defaultBackendConfigs() []backendConfig {
+	backends := []backendConfig{
+		{Type: backendFile, Level: "informational"},
+		{Type: backendConsole, Level: "notice"},
 	}
-	// and finally create syslog logger if needed
 	if r.syslog != "" {
-		var s *utils.SyslogHandler
-		s = utils.NewSyslogHandler(r.syslog, format, sLevel)
-		if s != nil {
-			r.logger.Handlers = r.logger.AddHandler(s)
-		}
+		backends = append(backends, backendConfig{Type: backendSyslog, Level: "notice"})
 	}
-	return err
+	return backends
 }
 
-// Initializes the Runner instance.
-func (r *Runner) initialize() error {
+// Initializes the Runner instance. explicit holds the config keys the user
+// set directly on the CLI (see parseArgs), so the "runner" section of the
+// input file can be layered in without clobbering them.
+func (r *Runner) initialize(explicit map[string]bool) error {
 	// let's collect the configuration
 	err := r.collect()
 	if err != nil {
 		return err
 	}
+	// layer in the "runner" section of the input file, CLI flags still win
+	if err := applyInputRunnerSection(r, explicit); err != nil {
+		return err
+	}
+	// per-testcase retry policy overrides, if any
+	policies, err := loadPolicies(r.input)
+	if err != nil {
+		return err
+	}
+	r.policies = policies
 	// check working dir value; if empty, redefine to default: '$HOME/results'
 	r.setWorkDir(r.workdir, r.tr.TestSet.Name)
 	// if this dir is not existent, create it
@@ -213,8 +280,18 @@ func (r *Runner) Run() {
 		lvl := params[0] // the first arg is logging level
 		msg := params[1] // the second arg is logging message
 		r.logger.LogS(lvl, msg)
+		r.events.publish(liveEvent{Level: lvl, Message: msg})
 	})
 
+	// start the optional status/report/events server before execution, so
+	// clients can connect and watch the run from its very first log line
+	var httpSrv *http.Server
+	if r.httpAddr != "" {
+		httpSrv = r.startHTTPServer()
+		r.logger.Notice(fmt.Sprintf("Live status server listening on %q\n", r.httpAddr))
+		defer httpSrv.Close()
+	}
+
 	// execution begins...
 	r.tr.Started = utils.Now()
 	r.logger.Notice(fmt.Sprintf("     Started: %s\n", r.tr.Started))
@@ -223,7 +300,15 @@ func (r *Runner) Run() {
 	if r.tr.TestSet != nil {
 		r.logger.Notice(fmt.Sprintf("# Starting Test set: %q\n",
 			r.tr.TestSet.Name))
-		r.tr.TestSet.Execute(&fn) // we pass a ptr to defined closure
+		if r.par {
+			r.runParallel()
+		} else {
+			// always routed through the per-case executor (policy.go), not a
+			// bare r.tr.TestSet.Execute(&fn) call, so the -t timeout and
+			// per-testcase timing are enforced the same way regardless of
+			// whether retries/fail-fast are actually configured
+			r.runSequentialWithPolicy(fn)
+		}
 	}
 
 	r.tr.Finished = utils.Now()
@@ -262,6 +347,13 @@ func (r *Runner) createXMLReport(filename string) error {
 	}
 	x += trXML
 
+	// append the timeline, if any testcase timing was recorded
+	tlXML, err := r.timelineXML()
+	if err != nil {
+		return err
+	}
+	x += tlXML
+
 	// write XML file
 	fout, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0755)
 	if err != nil {
@@ -279,6 +371,10 @@ func (r *Runner) createJSONReport(filename string) error {
 	if err != nil {
 		return err
 	}
+	json, err = r.mergeTimelineJSON(json)
+	if err != nil {
+		return err
+	}
 
 	//
 	f, err := os.Create(filename)
@@ -301,6 +397,7 @@ func (r *Runner) createHTMLReport(filename string) error {
 		return err
 	}
 	html += h
+	html += r.timelineHTML()
 	html += "</body>\n</html>\n"
 	// the file itself
 	fout, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0644)
@@ -355,6 +452,18 @@ func (r *Runner) CreateReports() {
 		}
 		r.logger.Notice(fmt.Sprintf("JSON report %q created.\n", filename))
 	}
+
+	// JUnit XML report upon request, for CI integration
+	if r.junit {
+		filename = filepath.ToSlash(path.Join(r.workdir, "junit.xml"))
+		err := r.createJUnitReport(filename)
+		if err != nil {
+			r.logger.Error("JUnit report could not be created.\n")
+			r.logger.Error(fmt.Sprintf("Reason: %s\n", err))
+			return
+		}
+		r.logger.Notice(fmt.Sprintf("JUnit report %q created.\n", filename))
+	}
 }
 
 // SetParallel sets the flag to execute the test cases in parallel.
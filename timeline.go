@@ -0,0 +1,97 @@
+package main
+
+/*
+ * timeline.go - renders the per-testcase timing data collected in
+ * r.timings (see parallel.go / policy.go) into each report
+ * format, so the HTML/XML/JSON reports can show a Gantt-style timeline of
+ * the run instead of letting the data dead-end after execution.
+ */
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// timelineHTML renders r.timings as an HTML table of start/finish/duration
+// per testcase, in the order the entries were appended to r.timings (it is
+// not sorted by start time). It's appended to the HTML report
+// body, right before </body>.
+func (r *Runner) timelineHTML() string {
+	if len(r.timings) == 0 {
+		return ""
+	}
+	s := "<h2>Timeline</h2>\n"
+	s += "<table class=\"timeline\">\n"
+	s += "<tr><th>Worker</th><th>Testcase</th><th>Started</th><th>Finished</th><th>Duration</th><th>Status</th></tr>\n"
+	for _, t := range r.timings {
+		duration := t.Finished.Sub(t.Started)
+		s += fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			t.Worker, t.Name, formatTimelineTime(t.Started), formatTimelineTime(t.Finished), duration, t.Status)
+	}
+	s += "</table>\n"
+	return s
+}
+
+// timelineXML renders r.timings as a <timeline> element, appended after the
+// TestReport's own XML in the XML report.
+func (r *Runner) timelineXML() (string, error) {
+	if len(r.timings) == 0 {
+		return "", nil
+	}
+	type timelineCase struct {
+		XMLName  xml.Name `xml:"testcase"`
+		Worker   int      `xml:"worker,attr"`
+		Name     string   `xml:"name,attr"`
+		Started  string   `xml:"started,attr"`
+		Finished string   `xml:"finished,attr"`
+		Status   string   `xml:"status,attr"`
+	}
+	type timeline struct {
+		XMLName xml.Name       `xml:"timeline"`
+		Cases   []timelineCase `xml:"testcase"`
+	}
+
+	tl := timeline{}
+	for _, t := range r.timings {
+		tl.Cases = append(tl.Cases, timelineCase{
+			Worker: t.Worker, Name: t.Name,
+			Started: formatTimelineTime(t.Started), Finished: formatTimelineTime(t.Finished),
+			Status: t.Status,
+		})
+	}
+	data, err := xml.MarshalIndent(tl, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// mergeTimelineJSON decodes the TestReport's own JSON, adds a "timings" key
+// holding r.timings, and re-encodes it - so report.json carries the same
+// timeline data as the HTML/XML reports without atf.TestReport needing to
+// know anything about it.
+func (r *Runner) mergeTimelineJSON(body string) (string, error) {
+	if len(r.timings) == 0 {
+		return body, nil
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return "", err
+	}
+	doc["timings"] = r.timings
+	merged, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
+
+// formatTimelineTime formats t for display, or "-" for the zero value (a
+// testcase that was never started, e.g. SKIPPED by fail-fast).
+func formatTimelineTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("15:04:05.000")
+}